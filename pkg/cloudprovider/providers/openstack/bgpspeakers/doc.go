@@ -0,0 +1,23 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bgpspeakers provides access to the Neutron bgp-speaker resource
+// exposed by the neutron-dynamic-routing extension. Gophercloud does not
+// vendor this extension, so this package implements just enough of it
+// (Get, Update, and the get_advertised_routes action) for the OpenStack
+// cloud provider's Routes implementation, in the same style as the vendored
+// networking/v2/extensions/layer3/routers package.
+package bgpspeakers
@@ -0,0 +1,74 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bgpspeakers
+
+import (
+	"github.com/gophercloud/gophercloud"
+)
+
+func resourceURL(c *gophercloud.ServiceClient, id string) string {
+	return c.ServiceURL("bgp-speakers", id)
+}
+
+func advertisedRoutesURL(c *gophercloud.ServiceClient, id string) string {
+	return c.ServiceURL("bgp-speakers", id, "get_advertised_routes")
+}
+
+// Get retrieves a specific bgp-speaker based on its unique ID.
+func Get(c *gophercloud.ServiceClient, id string) (r GetResult) {
+	_, r.Err = c.Get(resourceURL(c, id), &r.Body, nil)
+	return
+}
+
+// UpdateOptsBuilder allows extensions to add additional parameters to the
+// Update request.
+type UpdateOptsBuilder interface {
+	ToBGPSpeakerUpdateMap() (map[string]interface{}, error)
+}
+
+// UpdateOpts holds the fields of a bgp-speaker that can be updated. It
+// mirrors routers.UpdateOpts: AdvertisedRoutes replaces the full set of
+// prefixes the speaker advertises upstream.
+type UpdateOpts struct {
+	Name             *string            `json:"name,omitempty"`
+	AdvertisedRoutes *[]AdvertisedRoute `json:"advertised_routes,omitempty"`
+}
+
+// ToBGPSpeakerUpdateMap builds an update request body from UpdateOpts.
+func (opts UpdateOpts) ToBGPSpeakerUpdateMap() (map[string]interface{}, error) {
+	return gophercloud.BuildRequestBody(opts, "bgp_speaker")
+}
+
+// Update modifies the attributes of a bgp-speaker.
+func Update(c *gophercloud.ServiceClient, id string, opts UpdateOptsBuilder) (r UpdateResult) {
+	b, err := opts.ToBGPSpeakerUpdateMap()
+	if err != nil {
+		r.Err = err
+		return
+	}
+	_, r.Err = c.Put(resourceURL(c, id), b, &r.Body, &gophercloud.RequestOpts{
+		OkCodes: []int{200},
+	})
+	return
+}
+
+// GetAdvertisedRoutes fetches the prefixes a bgp-speaker is currently
+// advertising via the get_advertised_routes action.
+func GetAdvertisedRoutes(c *gophercloud.ServiceClient, id string) (r AdvertisedRoutesResult) {
+	_, r.Err = c.Get(advertisedRoutesURL(c, id), &r.Body, nil)
+	return
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2018 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bgpspeakers
+
+import (
+	"github.com/gophercloud/gophercloud"
+)
+
+// AdvertisedRoute is a single prefix a bgp-speaker advertises to its peers.
+// Unlike routers.Route it carries no nexthop: the speaker itself is the
+// next hop the upstream fabric routers learn.
+type AdvertisedRoute struct {
+	DestinationCIDR string `json:"destination"`
+}
+
+// BGPSpeaker represents a Neutron bgp-speaker resource.
+type BGPSpeaker struct {
+	ID                      string            `json:"id"`
+	Name                    string            `json:"name"`
+	LocalAS                 int               `json:"local_as"`
+	AdvertiseTenantNetworks bool              `json:"advertise_tenant_networks"`
+	AdvertisedRoutes        []AdvertisedRoute `json:"advertised_routes"`
+	Networks                []string          `json:"networks"`
+}
+
+type commonResult struct {
+	gophercloud.Result
+}
+
+// Extract interprets a GetResult/UpdateResult as a BGPSpeaker.
+func (r commonResult) Extract() (*BGPSpeaker, error) {
+	var s struct {
+		BGPSpeaker *BGPSpeaker `json:"bgp_speaker"`
+	}
+	err := r.ExtractInto(&s)
+	return s.BGPSpeaker, err
+}
+
+// GetResult is the result of a Get operation.
+type GetResult struct {
+	commonResult
+}
+
+// UpdateResult is the result of an Update operation.
+type UpdateResult struct {
+	commonResult
+}
+
+// AdvertisedRoutesResult is the result of a GetAdvertisedRoutes operation.
+type AdvertisedRoutesResult struct {
+	gophercloud.Result
+}
+
+// Extract interprets an AdvertisedRoutesResult as a slice of AdvertisedRoute.
+func (r AdvertisedRoutesResult) Extract() ([]AdvertisedRoute, error) {
+	var s struct {
+		AdvertisedRoutes []AdvertisedRoute `json:"advertised_routes"`
+	}
+	err := r.ExtractInto(&s)
+	return s.AdvertisedRoutes, err
+}
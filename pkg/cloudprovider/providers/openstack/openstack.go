@@ -0,0 +1,81 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import "time"
+
+// MyDuration is a time.Duration that can be parsed from the cloud.conf INI
+// format gcfg uses (e.g. "30s"), which time.Duration can't be directly:
+// gcfg's reflection-based fallback would try to scan the raw string into
+// the underlying int64 instead of calling time.ParseDuration.
+type MyDuration struct {
+	time.Duration
+}
+
+// UnmarshalText lets gcfg parse a MyDuration field with time.ParseDuration.
+func (d *MyDuration) UnmarshalText(text []byte) error {
+	res, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = res
+	return nil
+}
+
+// RouterOpts is used to configure routing in the OpenStack cloud provider's
+// Routes implementation. It is parsed out of the `[Route]` section of
+// cloud.conf via gcfg.
+type RouterOpts struct {
+	// RouterID is the router whose extra_routes are mutated by
+	// CreateRoute/DeleteRoute. Required unless UseSubnetRoutes is set.
+	RouterID string `gcfg:"router-id"`
+
+	// UseSubnetRoutes programs routes as host_routes on SubnetIDs instead of
+	// as extra_routes on RouterID. Useful in clouds where tenants don't own
+	// the router.
+	UseSubnetRoutes bool `gcfg:"use-subnet-routes"`
+	// SubnetIDs restricts UseSubnetRoutes to the listed subnets. Required
+	// when UseSubnetRoutes is set.
+	SubnetIDs []string `gcfg:"subnet-id"`
+
+	// RouteReconcileInterval, when positive, enables the batching layer:
+	// Create/DeleteRoute calls arriving within this window are folded into a
+	// single routers.Update/neutronports.Update pair, and drift against the
+	// live router is healed on this interval. Zero disables batching and
+	// falls back to one routers.Get/routers.Update per call.
+	RouteReconcileInterval MyDuration `gcfg:"route-reconcile-interval"`
+
+	// AllowExternalNextHop permits selectNextHop to fall back to a node's
+	// NodeExternalIP when no NodeInternalIP of the destination CIDR's
+	// address family is present.
+	AllowExternalNextHop bool `gcfg:"allow-external-next-hop"`
+
+	// BGPSpeakerID, when set, advertises/withdraws routes via this
+	// neutron-dynamic-routing bgp-speaker in addition to or instead of
+	// router extra_routes, depending on BGPAdvertiseMode.
+	BGPSpeakerID string `gcfg:"bgp-speaker-id"`
+	// BGPAdvertiseMode selects how routes are advertised when BGPSpeakerID
+	// is set: "router" (default) for extra_routes only, "bgp" for the
+	// bgp-speaker only, or "both" for both mechanisms.
+	BGPAdvertiseMode string `gcfg:"bgp-advertise-mode"`
+
+	// CentralizedRouterID, when set, is used in place of RouterID for
+	// extra_routes updates. A DVR RouterID's per-agent extra_routes can be
+	// lost on L3 agent failover, so a centralized router is used instead
+	// while RouterID remains the tenant-traffic router.
+	CentralizedRouterID string `gcfg:"centralized-router-id"`
+}
@@ -19,45 +19,197 @@ package openstack
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/asaskevich/govalidator"
 	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/compute/v2/servers"
 	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
 	neutronports "github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/subnets"
 
 	"github.com/golang/glog"
 	"k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/kubernetes/pkg/cloudprovider"
+	"k8s.io/kubernetes/pkg/cloudprovider/providers/openstack/bgpspeakers"
 )
 
 var errNoRouterID = errors.New("router-id not set in cloud provider config")
 
+// errNoSubnetIDs is returned by NewRoutes when UseSubnetRoutes is set but no
+// SubnetIDs are configured to program host_routes onto.
+var errNoSubnetIDs = errors.New("use-subnet-routes is set but subnet-id not set in cloud provider config")
+
+// errNoNextHopForFamily is returned by selectNextHop when a node has no
+// usable address of the destination CIDR's address family, so the route
+// controller can surface which family is missing rather than a bare
+// ErrNoAddressFound.
+type errNoNextHopForFamily struct {
+	family string
+	cidr   string
+}
+
+func (e *errNoNextHopForFamily) Error() string {
+	return fmt.Sprintf("no usable %s nexthop address found for destination %v", e.family, e.cidr)
+}
+
+// selectNextHop picks the address to use as a route's nexthop for destCIDR.
+// It prefers a NodeInternalIP of the matching family; when none is present
+// and allowExternalNextHop is set (RouterOpts.AllowExternalNextHop), it
+// falls back to a NodeExternalIP of that family. This supports dual-stack
+// and IPv6-only nodes where a node's v4 and v6 addresses may live on
+// different ports, or where only an external IP has the needed family.
+func selectNextHop(addrs []v1.NodeAddress, destCIDR string, allowExternalNextHop bool) (string, error) {
+	IP, _, _ := net.ParseCIDR(destCIDR)
+	CIDRisV4 := govalidator.IsIPv4(IP.String())
+	CIDRisV6 := govalidator.IsIPv6(IP.String())
+
+	family := "IPv4"
+	if CIDRisV6 {
+		family = "IPv6"
+	}
+
+	matchesFamily := func(addr string) bool {
+		return (govalidator.IsIPv4(addr) && CIDRisV4) || (govalidator.IsIPv6(addr) && CIDRisV6)
+	}
+
+	for _, addr := range addrs {
+		if addr.Type == v1.NodeInternalIP && matchesFamily(addr.Address) {
+			return addr.Address, nil
+		}
+	}
+
+	if allowExternalNextHop {
+		for _, addr := range addrs {
+			if addr.Type == v1.NodeExternalIP && matchesFamily(addr.Address) {
+				return addr.Address, nil
+			}
+		}
+	}
+
+	return "", &errNoNextHopForFamily{family: family, cidr: destCIDR}
+}
+
 // Routes implements the cloudprovider.Routes for OpenStack clouds
 type Routes struct {
 	compute *gophercloud.ServiceClient
 	network *gophercloud.ServiceClient
 	opts    RouterOpts
+
+	// routeOpsCh and the fields below back the optional batching layer
+	// enabled by RouterOpts.RouteReconcileInterval. When unset, CreateRoute
+	// and DeleteRoute fall back to doing a routers.Get/routers.Update per call.
+	routeOpsCh chan *routeBatchOp
+	stopCh     chan struct{}
+
+	mu               sync.Mutex
+	lastRouterRoutes []routers.Route // last known router state (seeded in NewRoutes, updated by each batch or heal), used to detect drift
+	clusterName      string          // learned from the most recent ListRoutes/CreateRoute/DeleteRoute call
+}
+
+// routerWithDistributed extends routers.Router with the Neutron `distributed`
+// attribute. The vendored routers.Router struct in this tree doesn't expose
+// it, so it's decoded separately via ExtractInto.
+type routerWithDistributed struct {
+	routers.Router
+	Distributed bool `json:"distributed"`
+}
+
+// getRouterDistributed reports whether routerID is running as a Neutron DVR
+// (distributed virtual router) rather than a centralized one.
+func getRouterDistributed(network *gophercloud.ServiceClient, routerID string) (bool, error) {
+	var s struct {
+		Router routerWithDistributed `json:"router"`
+	}
+	if err := routers.Get(network, routerID).ExtractInto(&s); err != nil {
+		return false, err
+	}
+	return s.Router.Distributed, nil
+}
+
+// extraRoutesRouterID returns the router whose extra_routes should be
+// mutated: RouterOpts.CentralizedRouterID when set (the usual choice for a
+// DVR router, whose per-agent extra_routes updates can be lost on
+// failover), falling back to RouterOpts.RouterID otherwise.
+func (r *Routes) extraRoutesRouterID() string {
+	if r.opts.CentralizedRouterID != "" {
+		return r.opts.CentralizedRouterID
+	}
+	return r.opts.RouterID
 }
 
 // NewRoutes creates a new instance of Routes
 func NewRoutes(compute *gophercloud.ServiceClient, network *gophercloud.ServiceClient, opts RouterOpts) (cloudprovider.Routes, error) {
-	if opts.RouterID == "" {
+	// A tenant that doesn't own a router can still run in UseSubnetRoutes
+	// mode, programming host_routes on its own subnets instead of extra_routes
+	// on a router it can't reach. In that mode router extra_routes are never
+	// listed or mutated, whatever BGPAdvertiseMode says ("both" then means
+	// host_routes plus bgp-speaker advertisements). SubnetIDs is what tells
+	// ListRoutes/CreateRoute which subnets to manage, so it's required
+	// whenever the mode is on.
+	if opts.UseSubnetRoutes && len(opts.SubnetIDs) == 0 {
+		return nil, errNoSubnetIDs
+	}
+	if opts.RouterID == "" && !opts.UseSubnetRoutes {
 		return nil, errNoRouterID
 	}
 
-	return &Routes{
+	if opts.RouterID != "" {
+		// Best-effort: log the router's DVR/centralized mode at startup. A
+		// transient failure here (Neutron briefly unreachable, rate-limited,
+		// ...) shouldn't prevent constructing Routes, so it's logged and
+		// otherwise ignored rather than returned.
+		if distributed, err := getRouterDistributed(network, opts.RouterID); err != nil {
+			glog.Warning("Unable to determine whether router ", opts.RouterID, " is distributed: ", err)
+		} else if distributed {
+			glog.Infof("Router %v is a distributed (DVR) router", opts.RouterID)
+			if opts.CentralizedRouterID != "" {
+				glog.Infof("Using centralized router %v for extra_routes; %v remains the tenant-traffic router", opts.CentralizedRouterID, opts.RouterID)
+			} else {
+				glog.Warningf("Router %v is distributed and no CentralizedRouterID fallback is configured; extra_routes updates may be lost on L3 agent failover", opts.RouterID)
+			}
+		} else {
+			glog.V(4).Infof("Router %v is centralized", opts.RouterID)
+		}
+	}
+
+	r := &Routes{
 		compute: compute,
 		network: network,
 		opts:    opts,
-	}, nil
+	}
+
+	if opts.RouteReconcileInterval.Duration > 0 {
+		r.routeOpsCh = make(chan *routeBatchOp, 100)
+		r.stopCh = make(chan struct{})
+
+		// Seed lastRouterRoutes from the live router so reconcileDrift has a
+		// baseline to heal against immediately, rather than being a no-op
+		// until the first successful flushRouteBatch populates it. Without
+		// this, any controller-manager restart left drift undetected until
+		// the next route mutation happened to come through.
+		if routerID := r.extraRoutesRouterID(); routerID != "" {
+			if router, err := routers.Get(network, routerID).Extract(); err != nil {
+				glog.Warning("Unable to seed last-known route state from router ", routerID, ": ", err)
+			} else {
+				r.lastRouterRoutes = router.Routes
+			}
+		}
+
+		go r.runRouteBatcher()
+	}
+
+	return r, nil
 }
 
 // ListRoutes lists all managed routes that belong to the specified clusterName
 func (r *Routes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudprovider.Route, error) {
 	glog.V(4).Infof("ListRoutes(%v)", clusterName)
+	r.recordClusterName(clusterName)
 
 	nodeNamesByAddr := make(map[string]types.NodeName)
 	err := foreachServer(r.compute, servers.ListOpts{}, func(srv *servers.Server) (bool, error) {
@@ -77,41 +229,665 @@ func (r *Routes) ListRoutes(ctx context.Context, clusterName string) ([]*cloudpr
 		return nil, err
 	}
 
-	router, err := routers.Get(r.network, r.opts.RouterID).Extract()
+	var routes []*cloudprovider.Route
+
+	if r.opts.UseSubnetRoutes {
+		subnetRoutes, err := listSubnetRoutes(r.network, r.opts.SubnetIDs, nodeNamesByAddr)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, subnetRoutes...)
+	}
+
+	useRouter, useBGP := routeAdvertiseModes(r.opts)
+	// UseSubnetRoutes takes priority over the router, exactly as it does in
+	// Create/DeleteRoute's if/else-if chain: when it's set, extra_routes on
+	// opts.RouterID aren't ours to manage and may hold unrelated or stale
+	// entries, so they're left out of the managed-route set entirely.
+	useRouter = useRouter && !r.opts.UseSubnetRoutes
+
+	if useBGP {
+		bgpRoutes, err := listBGPRoutes(r.network, r.opts.BGPSpeakerID)
+		if err != nil {
+			return nil, err
+		}
+		routes = append(routes, bgpRoutes...)
+	}
+
+	if useRouter {
+		router, err := routers.Get(r.network, r.extraRoutesRouterID()).Extract()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range router.Routes {
+			nodeName, foundNode := nodeNamesByAddr[item.NextHop]
+			route := cloudprovider.Route{
+				Name:            item.DestinationCIDR,
+				TargetNode:      nodeName, //empty if NextHop is unknown
+				Blackhole:       !foundNode,
+				DestinationCIDR: item.DestinationCIDR,
+			}
+			routes = append(routes, &route)
+		}
+	}
+
+	return routes, nil
+}
+
+// listSubnetRoutes lists the routes programmed as host_routes on the
+// configured subnets, used when RouterOpts.UseSubnetRoutes is enabled.
+func listSubnetRoutes(network *gophercloud.ServiceClient, subnetIDs []string, nodeNamesByAddr map[string]types.NodeName) ([]*cloudprovider.Route, error) {
+	var routes []*cloudprovider.Route
+	for _, subnetID := range subnetIDs {
+		subnet, err := subnets.Get(network, subnetID).Extract()
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range subnet.HostRoutes {
+			nodeName, foundNode := nodeNamesByAddr[item.NextHop]
+			route := cloudprovider.Route{
+				Name:            item.DestinationCIDR,
+				TargetNode:      nodeName, //empty if NextHop is unknown
+				Blackhole:       !foundNode,
+				DestinationCIDR: item.DestinationCIDR,
+			}
+			routes = append(routes, &route)
+		}
+	}
+	return routes, nil
+}
+
+// routeBatchKind identifies the kind of mutation a queued routeBatchOp asks
+// the batcher to fold into the next router/port update.
+type routeBatchKind int
+
+const (
+	routeBatchAddRoute routeBatchKind = iota
+	routeBatchRemoveRoute
+	routeBatchAddAddrPair
+	routeBatchRemoveAddrPair
+	routeBatchAdvertiseBGP
+	routeBatchWithdrawBGP
+)
+
+// routeBatchOp is a single route or allowed-address-pair mutation waiting to
+// be folded into the next batch window.
+type routeBatchOp struct {
+	kind     routeBatchKind
+	destCIDR string
+	nextHop  string // used by routeBatchAddRoute/routeBatchRemoveRoute
+	portID   string // used by routeBatchAddAddrPair/routeBatchRemoveAddrPair
+	result   chan error
+}
+
+// recordClusterName remembers the clusterName passed to the most recent
+// ListRoutes/CreateRoute/DeleteRoute call so the periodic reconciler can
+// call ListRoutes.
+func (r *Routes) recordClusterName(clusterName string) {
+	r.mu.Lock()
+	r.clusterName = clusterName
+	r.mu.Unlock()
+}
+
+// enqueueRouteOp submits a route or bgp-speaker advertisement mutation to
+// the batcher and blocks until the batch it lands in has been applied.
+func (r *Routes) enqueueRouteOp(kind routeBatchKind, destCIDR, nextHop string) error {
+	op := &routeBatchOp{kind: kind, destCIDR: destCIDR, nextHop: nextHop, result: make(chan error, 1)}
+	r.routeOpsCh <- op
+	return <-op.result
+}
+
+// runRouteBatcher coalesces route and allowed-address-pair mutations that
+// arrive within RouteReconcileInterval of each other into a single
+// routers.Update/neutronports.Update pair, and periodically re-reconciles
+// against ListRoutes to heal drift caused by changes made outside of this
+// controller.
+func (r *Routes) runRouteBatcher() {
+	reconcileTicker := time.NewTicker(r.opts.RouteReconcileInterval.Duration)
+	defer reconcileTicker.Stop()
+
+	var batch []*routeBatchOp
+	var flush <-chan time.Time
+
+	for {
+		select {
+		case op := <-r.routeOpsCh:
+			batch = append(batch, op)
+			if flush == nil {
+				flush = time.After(r.opts.RouteReconcileInterval.Duration)
+			}
+		case <-flush:
+			r.flushRouteBatch(batch)
+			batch = nil
+			flush = nil
+		case <-reconcileTicker.C:
+			if len(batch) == 0 {
+				r.reconcileDrift()
+			}
+		case <-r.stopCh:
+			return
+		}
+	}
+}
+
+// flushRouteBatch applies every queued mutation with a single routers.Get,
+// at most one routers.Update, and one neutronports.Update per distinct port.
+func (r *Routes) flushRouteBatch(batch []*routeBatchOp) {
+	if len(batch) == 0 {
+		return
+	}
+
+	glog.V(4).Infof("Reconciling a batch of %d route operations for router %v", len(batch), r.extraRoutesRouterID())
+
+	router, err := routers.Get(r.network, r.extraRoutesRouterID()).Extract()
+	if err != nil {
+		deliverBatchResult(batch, err)
+		return
+	}
+
+	routes := router.Routes
+	changed := false
+	for _, op := range batch {
+		switch op.kind {
+		case routeBatchAddRoute:
+			if !hasRoute(routes, op.destCIDR, op.nextHop) {
+				routes = append(routes, routers.Route{DestinationCIDR: op.destCIDR, NextHop: op.nextHop})
+				changed = true
+			}
+		case routeBatchRemoveRoute:
+			if i := routeIndex(routes, op.destCIDR, op.nextHop); i != -1 {
+				routes[i] = routes[len(routes)-1]
+				routes = routes[:len(routes)-1]
+				changed = true
+			}
+		}
+	}
+
+	var routeErr error
+	if changed {
+		routeErr = retryRouterUpdate(func() error {
+			_, err := routers.Update(r.network, router.ID, routers.UpdateOpts{Routes: routes}).Extract()
+			return err
+		})
+		if routeErr == nil {
+			r.mu.Lock()
+			r.lastRouterRoutes = routes
+			r.mu.Unlock()
+		}
+	}
+
+	// Every addr-pair op in the batch arrived paired with a route op via
+	// enqueueRouteAndAddrPairOps, so when the shared routers.Update that was
+	// meant to apply their paired route changes fails, the port mutation
+	// must not be applied either -- otherwise a destination CIDR's
+	// allowed-address-pair can land (or be removed) for a route that was
+	// never actually added (or removed) on the router.
+	var portErrs map[string]error
+	if routeErr != nil {
+		portErrs = make(map[string]error)
+		for _, op := range batch {
+			if op.kind == routeBatchAddAddrPair || op.kind == routeBatchRemoveAddrPair {
+				portErrs[op.portID] = routeErr
+			}
+		}
+	} else {
+		portErrs = r.flushAddrPairBatch(batch)
+	}
+	r.flushBGPBatch(batch)
+
+	for _, op := range batch {
+		switch op.kind {
+		case routeBatchAddRoute, routeBatchRemoveRoute:
+			op.result <- routeErr
+		case routeBatchAddAddrPair, routeBatchRemoveAddrPair:
+			op.result <- portErrs[op.portID]
+		}
+	}
+}
+
+// flushBGPBatch folds every queued bgp-speaker advertise/withdraw in batch
+// into a single bgpspeakers.Get/Update pair, the same read-modify-write race
+// that flushRouteBatch already avoids for router extra_routes. It delivers
+// results to the BGP ops directly, since flushRouteBatch's own delivery loop
+// only matches the router-route and addr-pair kinds.
+func (r *Routes) flushBGPBatch(batch []*routeBatchOp) {
+	var bgpOps []*routeBatchOp
+	for _, op := range batch {
+		if op.kind == routeBatchAdvertiseBGP || op.kind == routeBatchWithdrawBGP {
+			bgpOps = append(bgpOps, op)
+		}
+	}
+	if len(bgpOps) == 0 {
+		return
+	}
+
+	speaker, err := bgpspeakers.Get(r.network, r.opts.BGPSpeakerID).Extract()
+	if err != nil {
+		deliverBatchResult(bgpOps, err)
+		return
+	}
+
+	routes := speaker.AdvertisedRoutes
+	changed := false
+	for _, op := range bgpOps {
+		switch op.kind {
+		case routeBatchAdvertiseBGP:
+			found := false
+			for _, item := range routes {
+				if item.DestinationCIDR == op.destCIDR {
+					found = true
+					break
+				}
+			}
+			if !found {
+				routes = append(routes, bgpspeakers.AdvertisedRoute{DestinationCIDR: op.destCIDR})
+				changed = true
+			}
+		case routeBatchWithdrawBGP:
+			for i, item := range routes {
+				if item.DestinationCIDR == op.destCIDR {
+					routes[i] = routes[len(routes)-1]
+					routes = routes[:len(routes)-1]
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	var updateErr error
+	if changed {
+		_, updateErr = bgpspeakers.Update(r.network, r.opts.BGPSpeakerID, bgpspeakers.UpdateOpts{AdvertisedRoutes: &routes}).Extract()
+	}
+
+	deliverBatchResult(bgpOps, updateErr)
+}
+
+// flushAddrPairBatch unions the allowed-address-pair adds/removes destined
+// for the same port into a single neutronports.Update call.
+func (r *Routes) flushAddrPairBatch(batch []*routeBatchOp) map[string]error {
+	pairsByPort := make(map[string][]neutronports.AddressPair)
+	opsByPort := make(map[string][]*routeBatchOp)
+	portErrs := make(map[string]error)
+
+	for _, op := range batch {
+		if op.kind != routeBatchAddAddrPair && op.kind != routeBatchRemoveAddrPair {
+			continue
+		}
+		opsByPort[op.portID] = append(opsByPort[op.portID], op)
+		if _, fetched := pairsByPort[op.portID]; fetched {
+			continue
+		}
+		port, err := getPortByID(r.network, op.portID)
+		if err != nil {
+			portErrs[op.portID] = err
+			continue
+		}
+		pairsByPort[op.portID] = port.AllowedAddressPairs
+	}
+
+	for portID, ops := range opsByPort {
+		if _, failed := portErrs[portID]; failed {
+			continue
+		}
+		pairsByPort[portID] = applyAddrPairOps(pairsByPort[portID], ops)
+	}
+
+	for portID, pairs := range pairsByPort {
+		if _, err := neutronports.Update(r.network, portID, neutronports.UpdateOpts{AllowedAddressPairs: &pairs}).Extract(); err != nil {
+			portErrs[portID] = err
+		}
+	}
+
+	return portErrs
+}
+
+// applyAddrPairOps folds a single port's queued add/remove
+// allowed-address-pair ops into pairs, its starting state as fetched from
+// Neutron. Split out from flushAddrPairBatch so this union/diff logic can be
+// unit tested without a Neutron client.
+func applyAddrPairOps(pairs []neutronports.AddressPair, ops []*routeBatchOp) []neutronports.AddressPair {
+	for _, op := range ops {
+		switch op.kind {
+		case routeBatchAddAddrPair:
+			found := false
+			for _, pair := range pairs {
+				if pair.IPAddress == op.destCIDR {
+					found = true
+					break
+				}
+			}
+			if !found {
+				pairs = append(pairs, neutronports.AddressPair{IPAddress: op.destCIDR})
+			}
+		case routeBatchRemoveAddrPair:
+			for i, pair := range pairs {
+				if pair.IPAddress == op.destCIDR {
+					pairs[i] = pairs[len(pairs)-1]
+					pairs = pairs[:len(pairs)-1]
+					break
+				}
+			}
+		}
+	}
+	return pairs
+}
+
+// reconcileDrift re-lists routes through ListRoutes and re-applies any
+// previously batch-applied route missing from the live router, healing
+// drift from out-of-band router edits or a missed routers.Update.
+// lastRouterRoutes is seeded from the router at NewRoutes time and kept in
+// sync by every successful flushRouteBatch, so it survives a
+// controller-manager restart rather than healing only what this process
+// happened to batch itself.
+func (r *Routes) reconcileDrift() {
+	r.mu.Lock()
+	clusterName := r.clusterName
+	want := make([]routers.Route, len(r.lastRouterRoutes))
+	copy(want, r.lastRouterRoutes)
+	r.mu.Unlock()
+
+	if clusterName == "" || len(want) == 0 {
+		return
+	}
+
+	live, err := r.ListRoutes(context.Background(), clusterName)
+	if err != nil {
+		glog.Warning("Periodic route reconciliation: unable to list routes: ", err)
+		return
+	}
+
+	present := make(map[string]bool, len(live))
+	for _, route := range live {
+		present[route.Name] = true
+	}
+
+	var missing []routers.Route
+	for _, item := range want {
+		if !present[item.DestinationCIDR] {
+			missing = append(missing, item)
+		}
+	}
+	if len(missing) == 0 {
+		return
+	}
+
+	router, err := routers.Get(r.network, r.extraRoutesRouterID()).Extract()
+	if err != nil {
+		glog.Warning("Periodic route reconciliation: unable to fetch router: ", err)
+		return
+	}
+
+	routes := router.Routes
+	healed := false
+	for _, item := range missing {
+		if !hasRoute(routes, item.DestinationCIDR, item.NextHop) {
+			glog.Warningf("Periodic route reconciliation: healing missing route %v -> %v on router %v", item.DestinationCIDR, item.NextHop, router.ID)
+			routes = append(routes, item)
+			healed = true
+		}
+	}
+
+	if !healed {
+		return
+	}
+
+	err = retryRouterUpdate(func() error {
+		_, err := routers.Update(r.network, router.ID, routers.UpdateOpts{Routes: routes}).Extract()
+		return err
+	})
+	if err != nil {
+		glog.Warning("Periodic route reconciliation: unable to heal drift: ", err)
+		return
+	}
+
+	r.mu.Lock()
+	r.lastRouterRoutes = routes
+	r.mu.Unlock()
+}
+
+// oppositeRouteKind returns the routeBatchOp kind that undoes kind, used to
+// build a compensating op when a paired mutation fails partway through.
+func oppositeRouteKind(kind routeBatchKind) routeBatchKind {
+	switch kind {
+	case routeBatchAddRoute:
+		return routeBatchRemoveRoute
+	case routeBatchRemoveRoute:
+		return routeBatchAddRoute
+	case routeBatchAddAddrPair:
+		return routeBatchRemoveAddrPair
+	case routeBatchAdvertiseBGP:
+		return routeBatchWithdrawBGP
+	case routeBatchWithdrawBGP:
+		return routeBatchAdvertiseBGP
+	default:
+		return routeBatchAddAddrPair
+	}
+}
+
+// enqueueRouteAndAddrPairOps submits a route mutation and its paired
+// allowed-address-pair mutation together, sending both onto routeOpsCh before
+// waiting on either result. Submitting them back-to-back like this (rather
+// than waiting for the route op's batch to land before the addr-pair op is
+// even submitted) lets the batcher fold both into the same batch window, so
+// flushRouteBatch/flushAddrPairBatch still produce the single
+// routers.Update/neutronports.Update pair they're built for.
+func (r *Routes) enqueueRouteAndAddrPairOps(routeKind, pairKind routeBatchKind, destCIDR, nextHop, portID string) error {
+	routeOp := &routeBatchOp{kind: routeKind, destCIDR: destCIDR, nextHop: nextHop, result: make(chan error, 1)}
+	pairOp := &routeBatchOp{kind: pairKind, destCIDR: destCIDR, portID: portID, result: make(chan error, 1)}
+
+	r.routeOpsCh <- routeOp
+	r.routeOpsCh <- pairOp
+
+	if err := <-routeOp.result; err != nil {
+		return err
+	}
+	if err := <-pairOp.result; err != nil {
+		r.compensateRouteOp(oppositeRouteKind(routeKind), destCIDR, nextHop)
+		return err
+	}
+	return nil
+}
+
+// compensateRouteOp issues a best-effort compensating batched mutation when
+// a later step in Create/DeleteRoute fails after the route itself already
+// landed, mirroring the synchronous unwinders used by the unbatched path.
+func (r *Routes) compensateRouteOp(kind routeBatchKind, destCIDR, nextHop string) {
+	glog.V(4).Infof("Reverting batched route change for %v -> %v", destCIDR, nextHop)
+	if err := r.enqueueRouteOp(kind, destCIDR, nextHop); err != nil {
+		glog.Warning("Unable to revert batched route change during error unwind: ", err)
+	}
+}
+
+// compensateBGPOp issues a best-effort compensating batched bgp-speaker
+// mutation when a later step in Create/DeleteRoute fails after the
+// advertisement already landed, mirroring compensateRouteOp.
+func (r *Routes) compensateBGPOp(kind routeBatchKind, destCIDR string) {
+	glog.V(4).Infof("Reverting batched bgp-speaker advertisement for %v", destCIDR)
+	if err := r.enqueueRouteOp(kind, destCIDR, ""); err != nil {
+		glog.Warning("Unable to revert batched bgp-speaker advertisement during error unwind: ", err)
+	}
+}
+
+func deliverBatchResult(batch []*routeBatchOp, err error) {
+	for _, op := range batch {
+		op.result <- err
+	}
+}
+
+func hasRoute(routes []routers.Route, destCIDR, nextHop string) bool {
+	return routeIndex(routes, destCIDR, nextHop) != -1
+}
+
+func routeIndex(routes []routers.Route, destCIDR, nextHop string) int {
+	for i, item := range routes {
+		if item.DestinationCIDR == destCIDR && item.NextHop == nextHop {
+			return i
+		}
+	}
+	return -1
+}
+
+// routeAdvertiseModes returns which of the router-extra_routes and
+// bgp-speaker mechanisms RouterOpts.BGPAdvertiseMode enables. An empty
+// BGPSpeakerID or mode defaults to router-only, matching behavior before
+// BGP speaker support existed.
+func routeAdvertiseModes(opts RouterOpts) (useRouter, useBGP bool) {
+	if opts.BGPSpeakerID == "" {
+		return true, false
+	}
+	switch opts.BGPAdvertiseMode {
+	case "bgp":
+		return false, true
+	case "both":
+		return true, true
+	default:
+		return true, false
+	}
+}
+
+// listBGPRoutes lists the prefixes currently advertised by the configured
+// bgp-speaker. BGP-advertised routes have no per-node nexthop, so the
+// target node is always left empty.
+func listBGPRoutes(network *gophercloud.ServiceClient, speakerID string) ([]*cloudprovider.Route, error) {
+	advertised, err := bgpspeakers.GetAdvertisedRoutes(network, speakerID).Extract()
 	if err != nil {
 		return nil, err
 	}
 
 	var routes []*cloudprovider.Route
-	for _, item := range router.Routes {
-		nodeName, foundNode := nodeNamesByAddr[item.NextHop]
-		route := cloudprovider.Route{
+	for _, item := range advertised {
+		routes = append(routes, &cloudprovider.Route{
 			Name:            item.DestinationCIDR,
-			TargetNode:      nodeName, //empty if NextHop is unknown
-			Blackhole:       !foundNode,
 			DestinationCIDR: item.DestinationCIDR,
+		})
+	}
+	return routes, nil
+}
+
+// updateBGPAdvertisedRoutes replaces the set of prefixes a bgp-speaker
+// advertises and returns an unwinder symmetric to updateRoutes.
+func updateBGPAdvertisedRoutes(network *gophercloud.ServiceClient, speakerID string, newRoutes []bgpspeakers.AdvertisedRoute) (func(), error) {
+	speaker, err := bgpspeakers.Get(network, speakerID).Extract()
+	if err != nil {
+		return nil, err
+	}
+	origRoutes := speaker.AdvertisedRoutes // shallow copy
+
+	_, err = bgpspeakers.Update(network, speakerID, bgpspeakers.UpdateOpts{
+		AdvertisedRoutes: &newRoutes,
+	}).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	unwinder := func() {
+		glog.V(4).Info("Reverting advertised-routes change to bgp-speaker ", speakerID)
+		_, err := bgpspeakers.Update(network, speakerID, bgpspeakers.UpdateOpts{
+			AdvertisedRoutes: &origRoutes,
+		}).Extract()
+		if err != nil {
+			glog.Warning("Unable to reset advertised-routes during error unwind: ", err)
 		}
-		routes = append(routes, &route)
 	}
 
-	return routes, nil
+	return unwinder, nil
+}
+
+// advertiseBGPRoute adds destCIDR to the set of prefixes the configured
+// bgp-speaker advertises, a no-op if it is already advertised.
+func advertiseBGPRoute(network *gophercloud.ServiceClient, speakerID, destCIDR string) (func(), error) {
+	speaker, err := bgpspeakers.Get(network, speakerID).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range speaker.AdvertisedRoutes {
+		if item.DestinationCIDR == destCIDR {
+			glog.V(4).Infof("Skipping already-advertised bgp route: %v", destCIDR)
+			return func() {}, nil
+		}
+	}
+
+	newRoutes := append(speaker.AdvertisedRoutes, bgpspeakers.AdvertisedRoute{DestinationCIDR: destCIDR})
+	return updateBGPAdvertisedRoutes(network, speakerID, newRoutes)
+}
+
+// withdrawBGPRoute removes destCIDR from the set of prefixes the configured
+// bgp-speaker advertises, a no-op if it isn't currently advertised.
+func withdrawBGPRoute(network *gophercloud.ServiceClient, speakerID, destCIDR string) (func(), error) {
+	speaker, err := bgpspeakers.Get(network, speakerID).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	newRoutes := speaker.AdvertisedRoutes
+	index := -1
+	for i, item := range newRoutes {
+		if item.DestinationCIDR == destCIDR {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		glog.V(4).Infof("Skipping non-advertised bgp route: %v", destCIDR)
+		return func() {}, nil
+	}
+
+	newRoutes[index] = newRoutes[len(newRoutes)-1]
+	newRoutes = newRoutes[:len(newRoutes)-1]
+
+	return updateBGPAdvertisedRoutes(network, speakerID, newRoutes)
+}
+
+// retryRouterUpdate retries fn, which is expected to perform a single
+// routers.Update, with exponential backoff when Neutron answers 409
+// Conflict. DVR routers are known to return 409 when the L3 agent on each
+// node is concurrently rewriting the same router's extra_routes.
+func retryRouterUpdate(fn func() error) error {
+	const maxAttempts = 5
+	backoff := 100 * time.Millisecond
+	var err error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		err = fn()
+		if err == nil {
+			return nil
+		}
+		if _, ok := err.(gophercloud.ErrDefault409); !ok {
+			return err
+		}
+		if attempt == maxAttempts-1 {
+			break
+		}
+		glog.V(4).Infof("routers.Update got 409 (likely a DVR L3-agent race), retrying in %v", backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+	return err
 }
 
 func updateRoutes(network *gophercloud.ServiceClient, router *routers.Router, newRoutes []routers.Route) (func(), error) {
 	origRoutes := router.Routes // shallow copy
 
-	_, err := routers.Update(network, router.ID, routers.UpdateOpts{
-		Routes: newRoutes,
-	}).Extract()
+	err := retryRouterUpdate(func() error {
+		_, err := routers.Update(network, router.ID, routers.UpdateOpts{
+			Routes: newRoutes,
+		}).Extract()
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
 
 	unwinder := func() {
 		glog.V(4).Info("Reverting routes change to router ", router.ID)
-		_, err := routers.Update(network, router.ID, routers.UpdateOpts{
-			Routes: origRoutes,
-		}).Extract()
+		err := retryRouterUpdate(func() error {
+			_, err := routers.Update(network, router.ID, routers.UpdateOpts{
+				Routes: origRoutes,
+			}).Extract()
+			return err
+		})
 		if err != nil {
 			glog.Warning("Unable to reset routes during error unwind: ", err)
 		}
@@ -120,6 +896,70 @@ func updateRoutes(network *gophercloud.ServiceClient, router *routers.Router, ne
 	return unwinder, nil
 }
 
+// updateSubnetHostRoutes replaces the host_routes of the given subnet and
+// returns an unwinder symmetric to updateRoutes.
+func updateSubnetHostRoutes(network *gophercloud.ServiceClient, subnet *subnets.Subnet, newRoutes []subnets.HostRoute) (func(), error) {
+	origRoutes := subnet.HostRoutes // shallow copy
+
+	_, err := subnets.Update(network, subnet.ID, subnets.UpdateOpts{
+		HostRoutes: &newRoutes,
+	}).Extract()
+	if err != nil {
+		return nil, err
+	}
+
+	unwinder := func() {
+		glog.V(4).Info("Reverting host-routes change to subnet ", subnet.ID)
+		_, err := subnets.Update(network, subnet.ID, subnets.UpdateOpts{
+			HostRoutes: &origRoutes,
+		}).Extract()
+		if err != nil {
+			glog.Warning("Unable to reset host-routes during error unwind: ", err)
+		}
+	}
+
+	return unwinder, nil
+}
+
+// subnetForNode returns the subnet backing the node's port whose fixed IP
+// matches nexthop, restricted to opts.SubnetIDs when that list is non-empty.
+func subnetForNode(compute, network *gophercloud.ServiceClient, opts RouterOpts, targetNode types.NodeName, nexthop string) (*subnets.Subnet, error) {
+	portID, err := getPortIDByIP(compute, targetNode, nexthop)
+	if err != nil {
+		return nil, err
+	}
+	port, err := getPortByID(network, portID)
+	if err != nil {
+		return nil, err
+	}
+
+	var subnetID string
+	for _, fixedIP := range port.FixedIPs {
+		if fixedIP.IPAddress == nexthop {
+			subnetID = fixedIP.SubnetID
+			break
+		}
+	}
+	if subnetID == "" {
+		return nil, ErrNotFound
+	}
+
+	if len(opts.SubnetIDs) > 0 {
+		found := false
+		for _, id := range opts.SubnetIDs {
+			if id == subnetID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, ErrNotFound
+		}
+	}
+
+	return subnets.Get(network, subnetID).Extract()
+}
+
 func updateAllowedAddressPairs(network *gophercloud.ServiceClient, port *neutronports.Port, newPairs []neutronports.AddressPair) (func(), error) {
 	origPairs := port.AllowedAddressPairs // shallow copy
 
@@ -143,15 +983,18 @@ func updateAllowedAddressPairs(network *gophercloud.ServiceClient, port *neutron
 	return unwinder, nil
 }
 
-// CreateRoute creates the described managed route
+// CreateRoute creates the described managed route. It handles one
+// DestinationCIDR at a time by design: cloudprovider.Route is already
+// one-CIDR-per-call, and the route controller that drives this interface
+// calls CreateRoute/DeleteRoute once per entry in a node's Spec.PodCIDRs, so
+// dual-stack/multi-CIDR nodes are handled by multiple calls rather than by
+// this method iterating a node's CIDR list itself.
 func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint string, route *cloudprovider.Route) error {
 	glog.V(4).Infof("CreateRoute(%v, %v, %v)", clusterName, nameHint, route)
+	r.recordClusterName(clusterName)
 
 	onFailure := newCaller()
 
-	IP, _, _ := net.ParseCIDR(route.DestinationCIDR)
-	CIDRisV4 := govalidator.IsIPv4(IP.String())
-	CIDRisV6 := govalidator.IsIPv6(IP.String())
 	addrs, err := getAddressesByName(r.compute, route.TargetNode)
 
 	if err != nil {
@@ -160,46 +1003,111 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 		return ErrNoAddressFound
 	}
 
-	var nexthop string = ""
+	nexthop, err := selectNextHop(addrs, route.DestinationCIDR, r.opts.AllowExternalNextHop)
+	if err != nil {
+		return err
+	}
 
-	for _, addr := range addrs {
-		if addr.Type == v1.NodeInternalIP {
-			if (govalidator.IsIPv4(addr.Address) && CIDRisV4) || (govalidator.IsIPv6(addr.Address) && CIDRisV6) {
-				nexthop = addr.Address
-				break
+	glog.V(4).Infof("Using nexthop %v for node %v", nexthop, route.TargetNode)
+
+	useRouter, useBGP := routeAdvertiseModes(r.opts)
+
+	if useBGP {
+		if r.opts.RouteReconcileInterval.Duration > 0 {
+			// Fold into the same batching queue as router routes, rather
+			// than doing an unbatched read-modify-write against the single
+			// shared bgp-speaker on every call -- exactly the extra_routes
+			// race the batching queue exists to avoid.
+			if err := r.enqueueRouteOp(routeBatchAdvertiseBGP, route.DestinationCIDR, ""); err != nil {
+				return err
+			}
+			defer onFailure.call(func() { r.compensateBGPOp(routeBatchWithdrawBGP, route.DestinationCIDR) })
+		} else {
+			unwind, err := advertiseBGPRoute(r.network, r.opts.BGPSpeakerID, route.DestinationCIDR)
+			if err != nil {
+				return err
 			}
+			defer onFailure.call(unwind)
 		}
 	}
-	if nexthop == "" {
-		return ErrNoAddressFound
-	}
 
-	glog.V(4).Infof("Using nexthop %v for node %v", nexthop, route.TargetNode)
+	if r.opts.UseSubnetRoutes {
+		subnet, err := subnetForNode(r.compute, r.network, r.opts, route.TargetNode, nexthop)
+		if err != nil {
+			return err
+		}
 
-	router, err := routers.Get(r.network, r.opts.RouterID).Extract()
-	if err != nil {
-		return err
-	}
+		hostRoutes := subnet.HostRoutes
+		alreadyExists := false
+		for _, item := range hostRoutes {
+			if item.DestinationCIDR == route.DestinationCIDR && item.NextHop == nexthop {
+				alreadyExists = true
+				break
+			}
+		}
+		if alreadyExists {
+			glog.V(4).Infof("Skipping existing subnet route: %v", route)
+			onFailure.disarm()
+			return nil
+		}
 
-	routes := router.Routes
+		hostRoutes = append(hostRoutes, subnets.HostRoute{
+			DestinationCIDR: route.DestinationCIDR,
+			NextHop:         nexthop,
+		})
 
-	for _, item := range routes {
-		if item.DestinationCIDR == route.DestinationCIDR && item.NextHop == nexthop {
+		unwind, err := updateSubnetHostRoutes(r.network, subnet, hostRoutes)
+		if err != nil {
+			return err
+		}
+		defer onFailure.call(unwind)
+	} else if !useRouter {
+		// BGP-only mode: no router/subnet extra_routes to maintain.
+	} else if r.opts.RouteReconcileInterval.Duration > 0 {
+		portID, err := getPortIDByIP(r.compute, route.TargetNode, nexthop)
+		if err != nil {
+			return err
+		}
+
+		if err := r.enqueueRouteAndAddrPairOps(routeBatchAddRoute, routeBatchAddAddrPair, route.DestinationCIDR, nexthop, portID); err != nil {
+			return err
+		}
+
+		glog.V(4).Infof("Route created: %v", route)
+		onFailure.disarm()
+		return nil
+	} else {
+		router, err := routers.Get(r.network, r.extraRoutesRouterID()).Extract()
+		if err != nil {
+			return err
+		}
+
+		routes := router.Routes
+
+		alreadyExists := false
+		for _, item := range routes {
+			if item.DestinationCIDR == route.DestinationCIDR && item.NextHop == nexthop {
+				alreadyExists = true
+				break
+			}
+		}
+		if alreadyExists {
 			glog.V(4).Infof("Skipping existing route: %v", route)
+			onFailure.disarm()
 			return nil
 		}
-	}
 
-	routes = append(routes, routers.Route{
-		DestinationCIDR: route.DestinationCIDR,
-		NextHop:         nexthop,
-	})
+		routes = append(routes, routers.Route{
+			DestinationCIDR: route.DestinationCIDR,
+			NextHop:         nexthop,
+		})
 
-	unwind, err := updateRoutes(r.network, router, routes)
-	if err != nil {
-		return err
+		unwind, err := updateRoutes(r.network, router, routes)
+		if err != nil {
+			return err
+		}
+		defer onFailure.call(unwind)
 	}
-	defer onFailure.call(unwind)
 
 	// get the port of addr on target node.
 	portID, err := getPortIDByIP(r.compute, route.TargetNode, nexthop)
@@ -239,13 +1147,10 @@ func (r *Routes) CreateRoute(ctx context.Context, clusterName string, nameHint s
 // DeleteRoute deletes the specified managed route
 func (r *Routes) DeleteRoute(ctx context.Context, clusterName string, route *cloudprovider.Route) error {
 	glog.V(4).Infof("DeleteRoute(%v, %v)", clusterName, route)
+	r.recordClusterName(clusterName)
 
 	onFailure := newCaller()
 
-	IP, _, _ := net.ParseCIDR(route.DestinationCIDR)
-	CIDRisV4 := govalidator.IsIPv4(IP.String())
-	CIDRisV6 := govalidator.IsIPv6(IP.String())
-
 	addrs, err := getAddressesByName(r.compute, route.TargetNode)
 
 	if err != nil {
@@ -254,48 +1159,105 @@ func (r *Routes) DeleteRoute(ctx context.Context, clusterName string, route *clo
 		return ErrNoAddressFound
 	}
 
-	var nexthop string = ""
+	nexthop, err := selectNextHop(addrs, route.DestinationCIDR, r.opts.AllowExternalNextHop)
+	if err != nil {
+		return err
+	}
 
-	for _, addr := range addrs {
-		if addr.Type == v1.NodeInternalIP {
-			if (govalidator.IsIPv4(addr.Address) && CIDRisV4) || (govalidator.IsIPv6(addr.Address) && CIDRisV6) {
-				nexthop = addr.Address
-				break
+	useRouter, useBGP := routeAdvertiseModes(r.opts)
+
+	if useBGP {
+		if r.opts.RouteReconcileInterval.Duration > 0 {
+			if err := r.enqueueRouteOp(routeBatchWithdrawBGP, route.DestinationCIDR, ""); err != nil {
+				return err
 			}
+			defer onFailure.call(func() { r.compensateBGPOp(routeBatchAdvertiseBGP, route.DestinationCIDR) })
+		} else {
+			unwind, err := withdrawBGPRoute(r.network, r.opts.BGPSpeakerID, route.DestinationCIDR)
+			if err != nil {
+				return err
+			}
+			defer onFailure.call(unwind)
 		}
 	}
-	if nexthop == "" {
-		return ErrNoAddressFound
-	}
 
-	router, err := routers.Get(r.network, r.opts.RouterID).Extract()
-	if err != nil {
-		return err
-	}
+	if r.opts.UseSubnetRoutes {
+		subnet, err := subnetForNode(r.compute, r.network, r.opts, route.TargetNode, nexthop)
+		if err != nil {
+			return err
+		}
 
-	routes := router.Routes
-	index := -1
-	for i, item := range routes {
-		if item.DestinationCIDR == route.DestinationCIDR && item.NextHop == nexthop {
-			index = i
-			break
+		hostRoutes := subnet.HostRoutes
+		index := -1
+		for i, item := range hostRoutes {
+			if item.DestinationCIDR == route.DestinationCIDR && item.NextHop == nexthop {
+				index = i
+				break
+			}
 		}
-	}
 
-	if index == -1 {
-		glog.V(4).Infof("Skipping non-existent route: %v", route)
+		if index == -1 {
+			glog.V(4).Infof("Skipping non-existent subnet route: %v", route)
+			onFailure.disarm()
+			return nil
+		}
+
+		// Delete element `index`
+		hostRoutes[index] = hostRoutes[len(hostRoutes)-1]
+		hostRoutes = hostRoutes[:len(hostRoutes)-1]
+
+		unwind, err := updateSubnetHostRoutes(r.network, subnet, hostRoutes)
+		if err != nil {
+			return err
+		}
+		defer onFailure.call(unwind)
+	} else if !useRouter {
+		// BGP-only mode: no router/subnet extra_routes to maintain.
+	} else if r.opts.RouteReconcileInterval.Duration > 0 {
+		portID, err := getPortIDByIP(r.compute, route.TargetNode, nexthop)
+		if err != nil {
+			return err
+		}
+
+		if err := r.enqueueRouteAndAddrPairOps(routeBatchRemoveRoute, routeBatchRemoveAddrPair, route.DestinationCIDR, nexthop, portID); err != nil {
+			return err
+		}
+
+		glog.V(4).Infof("Route deleted: %v", route)
+		onFailure.disarm()
 		return nil
-	}
+	} else {
+		router, err := routers.Get(r.network, r.extraRoutesRouterID()).Extract()
+		if err != nil {
+			return err
+		}
+
+		routes := router.Routes
+		index := -1
+		for i, item := range routes {
+			if item.DestinationCIDR == route.DestinationCIDR && item.NextHop == nexthop {
+				index = i
+				break
+			}
+		}
 
-	// Delete element `index`
-	routes[index] = routes[len(routes)-1]
-	routes = routes[:len(routes)-1]
+		if index == -1 {
+			glog.V(4).Infof("Skipping non-existent route: %v", route)
+			onFailure.disarm()
+			return nil
+		}
 
-	unwind, err := updateRoutes(r.network, router, routes)
-	if err != nil {
-		return err
+		// Delete element `index`
+		routes[index] = routes[len(routes)-1]
+		routes = routes[:len(routes)-1]
+
+		unwind, err := updateRoutes(r.network, router, routes)
+		if err != nil {
+			return err
+		}
+		defer onFailure.call(unwind)
 	}
-	defer onFailure.call(unwind)
+	var index int
 
 	// get the port of nexthop on target node.
 	portID, err := getPortIDByIP(r.compute, route.TargetNode, nexthop)
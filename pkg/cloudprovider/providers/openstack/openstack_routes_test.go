@@ -0,0 +1,276 @@
+/*
+Copyright 2016 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package openstack
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/networking/v2/extensions/layer3/routers"
+	neutronports "github.com/gophercloud/gophercloud/openstack/networking/v2/ports"
+
+	"k8s.io/api/core/v1"
+)
+
+func TestSelectNextHop(t *testing.T) {
+	addrs := []v1.NodeAddress{
+		{Type: v1.NodeInternalIP, Address: "10.0.0.5"},
+		{Type: v1.NodeInternalIP, Address: "fd00::5"},
+		{Type: v1.NodeExternalIP, Address: "203.0.113.5"},
+	}
+
+	tests := []struct {
+		name                 string
+		addrs                []v1.NodeAddress
+		destCIDR             string
+		allowExternalNextHop bool
+		want                 string
+		wantErr              bool
+	}{
+		{
+			name:     "v4 internal",
+			addrs:    addrs,
+			destCIDR: "192.168.1.0/24",
+			want:     "10.0.0.5",
+		},
+		{
+			name:     "v6 internal",
+			addrs:    addrs,
+			destCIDR: "fd01::/64",
+			want:     "fd00::5",
+		},
+		{
+			name:                 "v4 external fallback",
+			addrs:                []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "203.0.113.5"}},
+			destCIDR:             "192.168.1.0/24",
+			allowExternalNextHop: true,
+			want:                 "203.0.113.5",
+		},
+		{
+			name:     "v4 external not used unless allowed",
+			addrs:    []v1.NodeAddress{{Type: v1.NodeExternalIP, Address: "203.0.113.5"}},
+			destCIDR: "192.168.1.0/24",
+			wantErr:  true,
+		},
+		{
+			name:     "no matching family",
+			addrs:    []v1.NodeAddress{{Type: v1.NodeInternalIP, Address: "10.0.0.5"}},
+			destCIDR: "fd01::/64",
+			wantErr:  true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := selectNextHop(tc.addrs, tc.destCIDR, tc.allowExternalNextHop)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("selectNextHop() = %v, nil, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectNextHop() unexpected error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("selectNextHop() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRouteAdvertiseModes(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          RouterOpts
+		wantUseRouter bool
+		wantUseBGP    bool
+	}{
+		{
+			name:          "no speaker defaults to router only",
+			opts:          RouterOpts{},
+			wantUseRouter: true,
+		},
+		{
+			name:          "mode router",
+			opts:          RouterOpts{BGPSpeakerID: "speaker-1", BGPAdvertiseMode: "router"},
+			wantUseRouter: true,
+		},
+		{
+			name:       "mode bgp",
+			opts:       RouterOpts{BGPSpeakerID: "speaker-1", BGPAdvertiseMode: "bgp"},
+			wantUseBGP: true,
+		},
+		{
+			name:          "mode both",
+			opts:          RouterOpts{BGPSpeakerID: "speaker-1", BGPAdvertiseMode: "both"},
+			wantUseRouter: true,
+			wantUseBGP:    true,
+		},
+		{
+			name:          "speaker set but mode unset defaults to router only",
+			opts:          RouterOpts{BGPSpeakerID: "speaker-1"},
+			wantUseRouter: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			useRouter, useBGP := routeAdvertiseModes(tc.opts)
+			if useRouter != tc.wantUseRouter || useBGP != tc.wantUseBGP {
+				t.Errorf("routeAdvertiseModes(%+v) = (%v, %v), want (%v, %v)", tc.opts, useRouter, useBGP, tc.wantUseRouter, tc.wantUseBGP)
+			}
+		})
+	}
+}
+
+func TestHasRouteAndRouteIndex(t *testing.T) {
+	routes := []routers.Route{
+		{DestinationCIDR: "10.0.0.0/24", NextHop: "192.168.0.1"},
+		{DestinationCIDR: "10.0.1.0/24", NextHop: "192.168.0.2"},
+	}
+
+	if !hasRoute(routes, "10.0.1.0/24", "192.168.0.2") {
+		t.Error("hasRoute() = false, want true for an existing route")
+	}
+	if hasRoute(routes, "10.0.2.0/24", "192.168.0.3") {
+		t.Error("hasRoute() = true, want false for a route that isn't present")
+	}
+	if hasRoute(routes, "10.0.0.0/24", "192.168.0.99") {
+		t.Error("hasRoute() = true, want false when the nexthop doesn't match")
+	}
+
+	if i := routeIndex(routes, "10.0.0.0/24", "192.168.0.1"); i != 0 {
+		t.Errorf("routeIndex() = %d, want 0", i)
+	}
+	if i := routeIndex(routes, "10.0.2.0/24", "192.168.0.3"); i != -1 {
+		t.Errorf("routeIndex() = %d, want -1", i)
+	}
+}
+
+func TestApplyAddrPairOps(t *testing.T) {
+	tests := []struct {
+		name  string
+		pairs []neutronports.AddressPair
+		ops   []*routeBatchOp
+		want  []string
+	}{
+		{
+			name: "add to empty",
+			ops: []*routeBatchOp{
+				{kind: routeBatchAddAddrPair, destCIDR: "10.0.0.0/24"},
+			},
+			want: []string{"10.0.0.0/24"},
+		},
+		{
+			name:  "add is a no-op when already present",
+			pairs: []neutronports.AddressPair{{IPAddress: "10.0.0.0/24"}},
+			ops: []*routeBatchOp{
+				{kind: routeBatchAddAddrPair, destCIDR: "10.0.0.0/24"},
+			},
+			want: []string{"10.0.0.0/24"},
+		},
+		{
+			name:  "remove",
+			pairs: []neutronports.AddressPair{{IPAddress: "10.0.0.0/24"}, {IPAddress: "10.0.1.0/24"}},
+			ops: []*routeBatchOp{
+				{kind: routeBatchRemoveAddrPair, destCIDR: "10.0.0.0/24"},
+			},
+			want: []string{"10.0.1.0/24"},
+		},
+		{
+			name: "remove is a no-op when absent",
+			ops: []*routeBatchOp{
+				{kind: routeBatchRemoveAddrPair, destCIDR: "10.0.0.0/24"},
+			},
+			want: nil,
+		},
+		{
+			name: "add then remove nets out to empty",
+			ops: []*routeBatchOp{
+				{kind: routeBatchAddAddrPair, destCIDR: "10.0.0.0/24"},
+				{kind: routeBatchRemoveAddrPair, destCIDR: "10.0.0.0/24"},
+			},
+			want: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := applyAddrPairOps(tc.pairs, tc.ops)
+			if len(got) != len(tc.want) {
+				t.Fatalf("applyAddrPairOps() = %v, want %v", got, tc.want)
+			}
+			gotSet := make(map[string]bool, len(got))
+			for _, pair := range got {
+				gotSet[pair.IPAddress] = true
+			}
+			for _, cidr := range tc.want {
+				if !gotSet[cidr] {
+					t.Errorf("applyAddrPairOps() = %v, missing %v", got, cidr)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryRouterUpdateSucceedsAfterConflicts(t *testing.T) {
+	calls := 0
+	err := retryRouterUpdate(func() error {
+		calls++
+		if calls < 3 {
+			return gophercloud.ErrDefault409{}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retryRouterUpdate() = %v, want nil", err)
+	}
+	if calls != 3 {
+		t.Errorf("retryRouterUpdate() called fn %d times, want 3", calls)
+	}
+}
+
+func TestRetryRouterUpdateGivesUpOnNon409(t *testing.T) {
+	wantErr := errors.New("boom")
+	calls := 0
+	err := retryRouterUpdate(func() error {
+		calls++
+		return wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("retryRouterUpdate() = %v, want %v", err, wantErr)
+	}
+	if calls != 1 {
+		t.Errorf("retryRouterUpdate() called fn %d times, want 1 for a non-409 error", calls)
+	}
+}
+
+func TestRetryRouterUpdateExhaustsRetriesOn409(t *testing.T) {
+	calls := 0
+	err := retryRouterUpdate(func() error {
+		calls++
+		return gophercloud.ErrDefault409{}
+	})
+	if _, ok := err.(gophercloud.ErrDefault409); !ok {
+		t.Fatalf("retryRouterUpdate() = %v, want a gophercloud.ErrDefault409", err)
+	}
+	if calls != 5 {
+		t.Errorf("retryRouterUpdate() called fn %d times, want 5 (maxAttempts)", calls)
+	}
+}